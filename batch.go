@@ -0,0 +1,152 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/FabianWe/gopherbouncedb"
+	"strings"
+)
+
+// maxBatchSize caps the number of rows written in a single multi-row
+// INSERT, keeping the generated statement well under MySQL's default
+// max_allowed_packet even for wide auth_user rows.
+const maxBatchSize = 500
+
+// preparedStmt returns a cached, re-usable *sql.Stmt for query, preparing it
+// on first use. It does not check the cached statement itself: callers that
+// get driver.ErrBadConn from it drop it with invalidatePreparedStmt and call
+// preparedStmt again to re-prepare it.
+func (s *MySQLUserStorage) preparedStmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if cached, ok := s.stmtCache.Load(query); ok {
+		return cached.(*sql.Stmt), nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	actual, loaded := s.stmtCache.LoadOrStore(query, stmt)
+	if loaded {
+		// someone else prepared the same query concurrently, keep theirs
+		stmt.Close()
+	}
+	return actual.(*sql.Stmt), nil
+}
+
+// invalidatePreparedStmt drops query from the cache, forcing the next
+// preparedStmt call to re-prepare it. Used when a cached statement's
+// connection turned out to be bad (driver.ErrBadConn).
+func (s *MySQLUserStorage) invalidatePreparedStmt(query string) {
+	if cached, ok := s.stmtCache.LoadAndDelete(query); ok {
+		cached.(*sql.Stmt).Close()
+	}
+}
+
+func isBadConn(err error) bool {
+	return errors.Is(err, driver.ErrBadConn)
+}
+
+// InsertUsers inserts many users in a single multi-row INSERT statement,
+// chunked at maxBatchSize rows per statement to stay well under
+// max_allowed_packet. It returns one gopherbouncedb.UserID per user, in the
+// same order as users.
+//
+// The IDs are derived from LAST_INSERT_ID() (the id of the first row in the
+// batch) plus an offset for each subsequent row. This relies on MySQL
+// guaranteeing consecutive auto-increment values within a single statement
+// when innodb_autoinc_lock_mode <= 1 (the default); with
+// innodb_autoinc_lock_mode=2 and statement-based replication this
+// assumption can break, so that mode is not supported here.
+//
+// Chunks are committed independently: if a later chunk fails, rows already
+// inserted by earlier chunks are not rolled back. In that case InsertUsers
+// still returns the IDs already generated for those rows (alongside the
+// error) so the caller can tell which users were actually created instead
+// of blindly retrying the whole batch and risking duplicate inserts.
+func (s *MySQLUserStorage) InsertUsers(users []*gopherbouncedb.UserModel) ([]gopherbouncedb.UserID, error) {
+	ids := make([]gopherbouncedb.UserID, 0, len(users))
+	for len(users) > 0 {
+		n := maxBatchSize
+		if n > len(users) {
+			n = len(users)
+		}
+		chunkIDs, err := s.insertUserChunk(users[:n])
+		ids = append(ids, chunkIDs...)
+		if err != nil {
+			return ids, err
+		}
+		users = users[n:]
+	}
+	return ids, nil
+}
+
+func (s *MySQLUserStorage) insertUserChunk(users []*gopherbouncedb.UserModel) ([]gopherbouncedb.UserID, error) {
+	const colsPerRow = 10
+	placeholders := make([]string, len(users))
+	args := make([]interface{}, 0, len(users)*colsPerRow)
+	for i, user := range users {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		args = append(args, user.Username, user.Password, user.EMail, user.FirstName,
+			user.LastName, user.IsSuperUser, user.IsStaff, user.IsActive,
+			user.DateJoined, user.LastLogin)
+	}
+	stmt := s.replacer.Apply(fmt.Sprintf(`INSERT INTO $USERS_TABLE_NAME$(
+username, password, email, first_name, last_name, is_superuser, is_staff,
+is_active, date_joined, last_login)
+VALUES%s;`, strings.Join(placeholders, ", ")))
+
+	res, err := s.db.Exec(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Exec has committed the rows at this point, so even if the bookkeeping
+	// below fails we still owe the caller whatever we can tell them about
+	// what got inserted instead of silently discarding it.
+	affected, affErr := res.RowsAffected()
+	firstID, idErr := res.LastInsertId()
+	switch {
+	case idErr != nil:
+		// Without LastInsertId we can't attribute real IDs to the inserted
+		// rows, but we can still report how many of them there were.
+		if affErr != nil {
+			return nil, idErr
+		}
+		return make([]gopherbouncedb.UserID, affected), idErr
+	case affErr != nil:
+		return nil, affErr
+	case int(affected) != len(users):
+		// Only the first `affected` rows are guaranteed to have landed with
+		// consecutive IDs starting at firstID; report those instead of
+		// dropping the whole chunk.
+		n := int(affected)
+		if n > len(users) {
+			n = len(users)
+		}
+		ids := make([]gopherbouncedb.UserID, n)
+		for i := 0; i < n; i++ {
+			ids[i] = gopherbouncedb.UserID(firstID + int64(i))
+		}
+		return ids, fmt.Errorf("InsertUsers: expected %d rows affected, got %d", len(users), affected)
+	}
+	ids := make([]gopherbouncedb.UserID, len(users))
+	for i := range users {
+		ids[i] = gopherbouncedb.UserID(firstID + int64(i))
+	}
+	return ids, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"fmt"
+	"github.com/FabianWe/gopherbouncedb"
+	"testing"
+	"time"
+)
+
+// TestInsertUsersBatch proves InsertUsers attributes the right
+// gopherbouncedb.UserID to the right row, both within a single chunk and
+// across a chunk boundary (maxBatchSize+5 users forces two chunks).
+//
+// The affected != len(users) error path in insertUserChunk isn't covered
+// here: against a real go-sql-driver/mysql connection, RowsAffected is
+// computed synchronously from the same OK packet as LastInsertId, so
+// there's no way to make them disagree without a mock driver. That branch
+// is reviewed by hand instead.
+func TestInsertUsersBatch(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	storage := NewMySQLUserStorage(db, nil)
+	if err := storage.InitUsers(); err != nil {
+		t.Fatalf("InitUsers: %s", err.Error())
+	}
+
+	const n = maxBatchSize + 5
+	users := make([]*gopherbouncedb.UserModel, n)
+	for i := range users {
+		users[i] = &gopherbouncedb.UserModel{
+			Username:   fmt.Sprintf("batch-user-%d", i),
+			EMail:      fmt.Sprintf("batch-user-%d@example.com", i),
+			Password:   "hash",
+			IsActive:   true,
+			DateJoined: time.Now(),
+		}
+	}
+
+	ids, err := storage.InsertUsers(users)
+	if err != nil {
+		t.Fatalf("InsertUsers: %s", err.Error())
+	}
+	if len(ids) != n {
+		t.Fatalf("InsertUsers returned %d ids, want %d", len(ids), n)
+	}
+
+	for i, id := range ids {
+		got, err := storage.GetUser(id)
+		if err != nil {
+			t.Fatalf("GetUser(%d) for users[%d]: %s", id, i, err.Error())
+		}
+		if got.Username != users[i].Username {
+			t.Errorf("id %d maps to username %q, want %q", id, got.Username, users[i].Username)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MySQLConfig bundles a DSN with the connection pool settings the
+// go-sql-driver/mysql docs recommend tuning explicitly, most importantly
+// ConnMaxLifetime: it should be set below the server's wait_timeout so the
+// pool never hands out a connection MySQL has already dropped.
+//
+// A zero value for MaxOpenConns, MaxIdleConns, ConnMaxLifetime or
+// ConnMaxIdleTime leaves the corresponding database/sql default in place.
+type MySQLConfig struct {
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// NewMySQLStorageWithConfig opens a connection pool for cfg.DSN, applies the
+// pool settings from cfg and wraps it in a MySQLStorage. Unlike
+// NewMySQLStorage, this replaces the old "SetMaxIdleConns(0)" workaround
+// that used to live in the tests: callers now configure ConnMaxLifetime
+// (and the rest of the pool) explicitly instead of rediscovering it.
+func NewMySQLStorageWithConfig(cfg *MySQLConfig, replaceMapping map[string]string) (*MySQLStorage, error) {
+	db, err := sql.Open("mysql", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return NewMySQLStorage(db, replaceMapping), nil
+}
+
+// Ping verifies that the underlying connection pool can still reach the
+// server, using ctx for cancellation / deadlines.
+func (s *MySQLStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Healthz is a convenience wrapper around Ping meant to back an HTTP health
+// check endpoint: it returns nil on success and a non-nil error otherwise,
+// without requiring the caller to construct a context for the common case.
+func (s *MySQLStorage) Healthz() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.Ping(ctx)
+}
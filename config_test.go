@@ -0,0 +1,71 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestDSN starts the ephemeral test server (unless MYSQL_HOST is set)
+// and returns a DSN pointing at it, registering a cleanup that terminates
+// the container.
+func newTestDSN(t *testing.T) string {
+	t.Helper()
+	dsn := setupMySQLConfigString()
+	if os.Getenv("MYSQL_HOST") == "" {
+		container, containerDSN, err := startTestContainer(context.Background(), defaultTestImage)
+		if err != nil {
+			t.Fatalf("can't start test container: %s", err.Error())
+		}
+		dsn = containerDSN
+		t.Cleanup(func() {
+			container.Terminate(context.Background())
+		})
+	}
+	return dsn
+}
+
+// TestNewMySQLStorageWithConfig proves MySQLConfig.MaxOpenConns is actually
+// applied to the opened *sql.DB (the only pool setting db.Stats() reports
+// back), and that Ping/Healthz work against it.
+func TestNewMySQLStorageWithConfig(t *testing.T) {
+	cfg := &MySQLConfig{
+		DSN:             newTestDSN(t),
+		MaxOpenConns:    7,
+		MaxIdleConns:    3,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: 30 * time.Second,
+	}
+
+	storage, err := NewMySQLStorageWithConfig(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewMySQLStorageWithConfig: %s", err.Error())
+	}
+	defer storage.db.Close()
+
+	if got := storage.db.Stats().MaxOpenConnections; got != cfg.MaxOpenConns {
+		t.Errorf("MaxOpenConnections = %d, want %d", got, cfg.MaxOpenConns)
+	}
+
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %s", err.Error())
+	}
+	if err := storage.Healthz(); err != nil {
+		t.Fatalf("Healthz: %s", err.Error())
+	}
+}
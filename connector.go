@@ -0,0 +1,73 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/go-sql-driver/mysql"
+)
+
+// TokenProvider returns a fresh password / token to use for the next
+// connection attempt, e.g. an AWS RDS IAM auth token or an Azure AD access
+// token. It is invoked via the mysql.BeforeConnect option, i.e. once per
+// new physical connection rather than once for the lifetime of the pool,
+// since those tokens typically expire after a few minutes.
+type TokenProvider func(ctx context.Context) (string, error)
+
+// NewMySQLConnector builds a driver.Connector from cfg. If tlsConfig is
+// non-nil it is registered with the driver via mysql.RegisterTLSConfig and
+// wired into cfg.TLSConfig. If tokenProvider is non-nil it is applied via
+// the mysql.BeforeConnect option to refresh cfg.Passwd on every new
+// connection, which is what rotating IAM credentials (RDS, Aurora, Azure
+// AD) require.
+//
+// cfg is cloned, so the caller's copy is left untouched.
+func NewMySQLConnector(cfg *mysql.Config, tlsConfig *tls.Config, tokenProvider TokenProvider) (driver.Connector, error) {
+	cfg = cfg.Clone()
+	if tlsConfig != nil {
+		tlsConfigName := "gopherbouncemysql-" + cfg.Addr
+		if err := mysql.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tlsConfigName
+	}
+	if tokenProvider != nil {
+		beforeConnect := func(ctx context.Context, c *mysql.Config) error {
+			token, err := tokenProvider(ctx)
+			if err != nil {
+				return err
+			}
+			c.Passwd = token
+			return nil
+		}
+		if err := cfg.Apply(mysql.BeforeConnect(beforeConnect)); err != nil {
+			return nil, err
+		}
+	}
+	return mysql.NewConnector(cfg)
+}
+
+// NewMySQLStorageWithConnector opens a connection pool from a pre-built
+// driver.Connector instead of a DSN string, and wraps it in a MySQLStorage.
+// Use it together with NewMySQLConnector when a plain
+// "user:pw@tcp(host:port)/db" DSN string can't express what's needed -
+// custom TLS, parseTime, or rotating IAM credentials.
+func NewMySQLStorageWithConnector(connector driver.Connector, replaceMapping map[string]string) *MySQLStorage {
+	db := sql.OpenDB(connector)
+	return NewMySQLStorage(db, replaceMapping)
+}
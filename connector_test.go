@@ -0,0 +1,60 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"testing"
+)
+
+// TestNewMySQLConnectorRotatesToken proves that a TokenProvider passed to
+// NewMySQLConnector is invoked on every Connect call, not just once at
+// connector construction time. beforeConnect (and therefore the provider)
+// runs before any network I/O, so a provider error short-circuits Connect
+// without needing a real server.
+func TestNewMySQLConnectorRotatesToken(t *testing.T) {
+	cfg := mysql.NewConfig()
+	cfg.Addr = "127.0.0.1:3306"
+	cfg.User = "mysql"
+
+	var calls int
+	sentinel := errors.New("token refresh failed")
+	provider := func(ctx context.Context) (string, error) {
+		calls++
+		return "", sentinel
+	}
+
+	connector, err := NewMySQLConnector(cfg, nil, provider)
+	if err != nil {
+		t.Fatalf("NewMySQLConnector: %s", err.Error())
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, err := connector.Connect(context.Background()); !errors.Is(err, sentinel) {
+			t.Fatalf("Connect() #%d error = %v, want %v", i, err, sentinel)
+		}
+		if calls != i {
+			t.Fatalf("TokenProvider called %d times after Connect() #%d, want %d", calls, i, i)
+		}
+	}
+
+	// the caller's cfg must be left untouched, since NewMySQLConnector
+	// promises to clone it.
+	if cfg.Passwd != "" {
+		t.Errorf("caller's cfg.Passwd = %q, want unchanged empty string", cfg.Passwd)
+	}
+}
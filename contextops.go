@@ -0,0 +1,214 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"github.com/FabianWe/gopherbouncedb"
+	"time"
+)
+
+// ContextBridge is implemented by storages in this package that offer
+// context-aware (*Context) variants of the gopherbouncedb.UserStorage /
+// gopherbouncedb.SessionStorage get, insert, update and delete operations;
+// the interface itself only requires PingContext. InitUsers and
+// InitSessions have no *Context variant (MigrateTo takes a context), and
+// UpdateUserContext always writes every column. gopherbouncedb itself is
+// context-less (it is built on top of plain database/sql calls), so this
+// is a purely additive, package-local extension: it re-uses the SQL text
+// built by MySQLUserQueries / MySQLSessionQueries, but issues it with
+// QueryContext / ExecContext instead of the context-less Query / Exec.
+type ContextBridge interface {
+	PingContext(ctx context.Context) error
+}
+
+// scanUser scans a single auth_user row into a gopherbouncedb.UserModel.
+func scanUser(scan func(dest ...interface{}) error) (*gopherbouncedb.UserModel, error) {
+	user := &gopherbouncedb.UserModel{}
+	bridge := NewMySQLBridge()
+	dateJoined := bridge.TimeScanType()
+	lastLogin := bridge.TimeScanType()
+	if err := scan(&user.ID, &user.Username, &user.Password, &user.EMail,
+		&user.FirstName, &user.LastName, &user.IsSuperUser, &user.IsStaff,
+		&user.IsActive, dateJoined, lastLogin); err != nil {
+		return nil, err
+	}
+	var err error
+	if user.DateJoined, err = bridge.ConvertTimeScanType(dateJoined); err != nil {
+		return nil, err
+	}
+	if user.LastLogin, err = bridge.ConvertTimeScanType(lastLogin); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserContext is the context-aware variant of GetUser. It is a hot path
+// (called on every authenticated request), so it runs through the prepared
+// statement cache instead of preparing GetUserS on every call.
+func (s *MySQLUserStorage) GetUserContext(ctx context.Context, id gopherbouncedb.UserID) (*gopherbouncedb.UserModel, error) {
+	query := s.queries.GetUser()
+	stmt, err := s.preparedStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	user, err := scanUser(stmt.QueryRowContext(ctx, id).Scan)
+	if isBadConn(err) {
+		s.invalidatePreparedStmt(query)
+		if stmt, err = s.preparedStmt(ctx, query); err != nil {
+			return nil, err
+		}
+		user, err = scanUser(stmt.QueryRowContext(ctx, id).Scan)
+	}
+	return user, err
+}
+
+// GetUserByNameContext is the context-aware, prepared-statement-cached
+// variant of GetUserByName, see GetUserContext.
+func (s *MySQLUserStorage) GetUserByNameContext(ctx context.Context, username string) (*gopherbouncedb.UserModel, error) {
+	query := s.queries.GetUserByName()
+	stmt, err := s.preparedStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	user, err := scanUser(stmt.QueryRowContext(ctx, username).Scan)
+	if isBadConn(err) {
+		s.invalidatePreparedStmt(query)
+		if stmt, err = s.preparedStmt(ctx, query); err != nil {
+			return nil, err
+		}
+		user, err = scanUser(stmt.QueryRowContext(ctx, username).Scan)
+	}
+	return user, err
+}
+
+// GetUserByEmailContext is the context-aware, prepared-statement-cached
+// variant of GetUserByEmail, see GetUserContext.
+func (s *MySQLUserStorage) GetUserByEmailContext(ctx context.Context, email string) (*gopherbouncedb.UserModel, error) {
+	query := s.queries.GetUserByEmail()
+	stmt, err := s.preparedStmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	user, err := scanUser(stmt.QueryRowContext(ctx, email).Scan)
+	if isBadConn(err) {
+		s.invalidatePreparedStmt(query)
+		if stmt, err = s.preparedStmt(ctx, query); err != nil {
+			return nil, err
+		}
+		user, err = scanUser(stmt.QueryRowContext(ctx, email).Scan)
+	}
+	return user, err
+}
+
+// InsertUserContext is the context-aware, prepared-statement-cached variant
+// of InsertUser, see GetUserContext.
+func (s *MySQLUserStorage) InsertUserContext(ctx context.Context, user *gopherbouncedb.UserModel) (gopherbouncedb.UserID, error) {
+	query := s.queries.InsertUser()
+	args := []interface{}{user.Username, user.Password, user.EMail, user.FirstName, user.LastName,
+		user.IsSuperUser, user.IsStaff, user.IsActive, user.DateJoined, user.LastLogin}
+	stmt, err := s.preparedStmt(ctx, query)
+	if err != nil {
+		return -1, err
+	}
+	res, err := stmt.ExecContext(ctx, args...)
+	if isBadConn(err) {
+		s.invalidatePreparedStmt(query)
+		if stmt, err = s.preparedStmt(ctx, query); err != nil {
+			return -1, err
+		}
+		res, err = stmt.ExecContext(ctx, args...)
+	}
+	if err != nil {
+		return -1, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return -1, err
+	}
+	return gopherbouncedb.UserID(id), nil
+}
+
+// UpdateUserContext is the context-aware variant of UpdateUser. It always
+// updates all columns; there is no way to restrict it to a set of fields.
+func (s *MySQLUserStorage) UpdateUserContext(ctx context.Context, id gopherbouncedb.UserID, user *gopherbouncedb.UserModel) error {
+	_, err := s.db.ExecContext(ctx, s.queries.UpdateUser(nil),
+		user.Username, user.Password, user.EMail, user.FirstName, user.LastName,
+		user.IsSuperUser, user.IsStaff, user.IsActive, user.DateJoined, user.LastLogin, id)
+	return err
+}
+
+// DeleteUserContext is the context-aware variant of DeleteUser.
+func (s *MySQLUserStorage) DeleteUserContext(ctx context.Context, id gopherbouncedb.UserID) error {
+	_, err := s.db.ExecContext(ctx, s.queries.DeleteUser(), id)
+	return err
+}
+
+// PingContext pings the underlying connection pool, satisfying
+// ContextBridge.
+func (s *MySQLUserStorage) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// scanSession scans a single auth_session row into a gopherbouncedb.SessionEntry.
+func scanSession(scan func(dest ...interface{}) error) (*gopherbouncedb.SessionEntry, error) {
+	entry := &gopherbouncedb.SessionEntry{}
+	bridge := NewMySQLBridge()
+	expireDate := bridge.TimeScanType()
+	if err := scan(&entry.Key, &entry.User, expireDate); err != nil {
+		return nil, err
+	}
+	var err error
+	if entry.ExpireDate, err = bridge.ConvertTimeScanType(expireDate); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// InsertSessionContext is the context-aware variant of InsertSession.
+func (s *MySQLSessionStorage) InsertSessionContext(ctx context.Context, entry *gopherbouncedb.SessionEntry) error {
+	_, err := s.db.ExecContext(ctx, s.queries.InsertSession(), entry.Key, entry.User, entry.ExpireDate)
+	return err
+}
+
+// GetSessionContext is the context-aware variant of GetSession.
+func (s *MySQLSessionStorage) GetSessionContext(ctx context.Context, key string) (*gopherbouncedb.SessionEntry, error) {
+	row := s.db.QueryRowContext(ctx, s.queries.GetSession(), key)
+	return scanSession(row.Scan)
+}
+
+// DeleteSessionContext is the context-aware variant of DeleteSession.
+func (s *MySQLSessionStorage) DeleteSessionContext(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.queries.DeleteSession(), key)
+	return err
+}
+
+// CleanUpSessionContext is the context-aware variant of CleanUpSession.
+func (s *MySQLSessionStorage) CleanUpSessionContext(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.queries.CleanUpSession(), time.Now())
+	return err
+}
+
+// DeleteForUserSessionContext is the context-aware variant of DeleteForUserSession.
+func (s *MySQLSessionStorage) DeleteForUserSessionContext(ctx context.Context, userID gopherbouncedb.UserID) error {
+	_, err := s.db.ExecContext(ctx, s.queries.DeleteForUserSession(), userID)
+	return err
+}
+
+// PingContext pings the underlying connection pool, satisfying
+// ContextBridge.
+func (s *MySQLSessionStorage) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"github.com/FabianWe/gopherbouncedb"
+	"testing"
+	"time"
+)
+
+// TestUserContextOps exercises the *Context variants of MySQLUserStorage
+// end to end, including a forced prepared-statement invalidation to prove
+// GetUserContext re-prepares after a cache miss instead of only on the
+// very first call.
+func TestUserContextOps(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	storage := NewMySQLUserStorage(db, nil)
+	if err := storage.InitUsers(); err != nil {
+		t.Fatalf("InitUsers: %s", err.Error())
+	}
+	ctx := context.Background()
+
+	if err := storage.PingContext(ctx); err != nil {
+		t.Fatalf("PingContext: %s", err.Error())
+	}
+
+	user := &gopherbouncedb.UserModel{
+		Username:   "context-user",
+		EMail:      "context-user@example.com",
+		Password:   "hash",
+		IsActive:   true,
+		DateJoined: time.Now(),
+	}
+	id, err := storage.InsertUserContext(ctx, user)
+	if err != nil {
+		t.Fatalf("InsertUserContext: %s", err.Error())
+	}
+
+	got, err := storage.GetUserContext(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserContext: %s", err.Error())
+	}
+	if got.Username != user.Username {
+		t.Errorf("GetUserContext username = %q, want %q", got.Username, user.Username)
+	}
+
+	// force a re-prepare and confirm the newly prepared statement works
+	storage.invalidatePreparedStmt(storage.queries.GetUser())
+	if got, err = storage.GetUserContext(ctx, id); err != nil {
+		t.Fatalf("GetUserContext after invalidate: %s", err.Error())
+	}
+	if got.Username != user.Username {
+		t.Errorf("GetUserContext after invalidate username = %q, want %q", got.Username, user.Username)
+	}
+
+	if got, err = storage.GetUserByNameContext(ctx, user.Username); err != nil {
+		t.Fatalf("GetUserByNameContext: %s", err.Error())
+	}
+	if got.ID != id {
+		t.Errorf("GetUserByNameContext id = %d, want %d", got.ID, id)
+	}
+
+	if got, err = storage.GetUserByEmailContext(ctx, user.EMail); err != nil {
+		t.Fatalf("GetUserByEmailContext: %s", err.Error())
+	}
+	if got.ID != id {
+		t.Errorf("GetUserByEmailContext id = %d, want %d", got.ID, id)
+	}
+
+	user.FirstName = "Updated"
+	if err := storage.UpdateUserContext(ctx, id, user); err != nil {
+		t.Fatalf("UpdateUserContext: %s", err.Error())
+	}
+	if got, err = storage.GetUserContext(ctx, id); err != nil {
+		t.Fatalf("GetUserContext after update: %s", err.Error())
+	}
+	if got.FirstName != "Updated" {
+		t.Errorf("FirstName after UpdateUserContext = %q, want %q", got.FirstName, "Updated")
+	}
+
+	if err := storage.DeleteUserContext(ctx, id); err != nil {
+		t.Fatalf("DeleteUserContext: %s", err.Error())
+	}
+	if _, err = storage.GetUserContext(ctx, id); err == nil {
+		t.Error("GetUserContext after DeleteUserContext: expected an error, got nil")
+	}
+}
+
+// TestSessionContextOps exercises the *Context variants of
+// MySQLSessionStorage end to end.
+func TestSessionContextOps(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	userStorage := NewMySQLUserStorage(db, nil)
+	if err := userStorage.InitUsers(); err != nil {
+		t.Fatalf("InitUsers: %s", err.Error())
+	}
+	sessionStorage := NewMySQLSessionStorage(db, nil)
+	if err := sessionStorage.InitSessions(); err != nil {
+		t.Fatalf("InitSessions: %s", err.Error())
+	}
+	ctx := context.Background()
+
+	if err := sessionStorage.PingContext(ctx); err != nil {
+		t.Fatalf("PingContext: %s", err.Error())
+	}
+
+	user := &gopherbouncedb.UserModel{
+		Username:   "session-context-user",
+		EMail:      "session-context-user@example.com",
+		Password:   "hash",
+		IsActive:   true,
+		DateJoined: time.Now(),
+	}
+	userID, err := userStorage.InsertUserContext(ctx, user)
+	if err != nil {
+		t.Fatalf("InsertUserContext: %s", err.Error())
+	}
+
+	entry, err := gopherbouncedb.NewSessionWithKey(userID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("NewSessionWithKey: %s", err.Error())
+	}
+	if err := sessionStorage.InsertSessionContext(ctx, entry); err != nil {
+		t.Fatalf("InsertSessionContext: %s", err.Error())
+	}
+
+	got, err := sessionStorage.GetSessionContext(ctx, entry.Key)
+	if err != nil {
+		t.Fatalf("GetSessionContext: %s", err.Error())
+	}
+	if got.User != userID {
+		t.Errorf("GetSessionContext user = %d, want %d", got.User, userID)
+	}
+
+	if err := sessionStorage.DeleteForUserSessionContext(ctx, userID); err != nil {
+		t.Fatalf("DeleteForUserSessionContext: %s", err.Error())
+	}
+	if _, err = sessionStorage.GetSessionContext(ctx, entry.Key); err == nil {
+		t.Error("GetSessionContext after DeleteForUserSessionContext: expected an error, got nil")
+	}
+
+	expired, err := gopherbouncedb.NewSessionWithKey(userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("NewSessionWithKey: %s", err.Error())
+	}
+	if err := sessionStorage.InsertSessionContext(ctx, expired); err != nil {
+		t.Fatalf("InsertSessionContext (expired): %s", err.Error())
+	}
+	if err := sessionStorage.CleanUpSessionContext(ctx); err != nil {
+		t.Fatalf("CleanUpSessionContext: %s", err.Error())
+	}
+	if _, err = sessionStorage.GetSessionContext(ctx, expired.Key); err == nil {
+		t.Error("GetSessionContext after CleanUpSessionContext: expected an error, got nil")
+	}
+
+	another, err := gopherbouncedb.NewSessionWithKey(userID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("NewSessionWithKey: %s", err.Error())
+	}
+	if err := sessionStorage.InsertSessionContext(ctx, another); err != nil {
+		t.Fatalf("InsertSessionContext: %s", err.Error())
+	}
+	if err := sessionStorage.DeleteSessionContext(ctx, another.Key); err != nil {
+		t.Fatalf("DeleteSessionContext: %s", err.Error())
+	}
+	if _, err = sessionStorage.GetSessionContext(ctx, another.Key); err == nil {
+		t.Error("GetSessionContext after DeleteSessionContext: expected an error, got nil")
+	}
+}
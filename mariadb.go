@@ -0,0 +1,211 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"database/sql"
+	"github.com/FabianWe/gopherbouncedb"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// MariaDBKeyExists is the "plain" duplicate key error number, shared with
+	// MySQL.
+	MariaDBKeyExists = 1062
+	// MariaDBKeyExistsWithKeyName is the error number MariaDB reports for a
+	// duplicate key violation that also names the offending key, as raised
+	// e.g. during LOAD DATA or some ALTER TABLE statements.
+	MariaDBKeyExistsWithKeyName = 1586
+)
+
+// MariaDBBridge is the gopherbouncedb.SQLBridge implementation for MariaDB.
+//
+// It behaves exactly like MySQLBridge, except that IsDuplicateInsert and
+// IsDuplicateUpdate also accept MariaDBKeyExistsWithKeyName as a duplicate
+// key error.
+type MariaDBBridge struct {
+	MySQLBridge
+}
+
+func NewMariaDBBridge() MariaDBBridge {
+	return MariaDBBridge{NewMySQLBridge()}
+}
+
+func (b MariaDBBridge) IsDuplicateInsert(err error) bool {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		return mysqlErr.Number == MariaDBKeyExists || mysqlErr.Number == MariaDBKeyExistsWithKeyName
+	}
+	return false
+}
+
+func (b MariaDBBridge) IsDuplicateUpdate(err error) bool {
+	return b.IsDuplicateInsert(err)
+}
+
+// MariaDBUserQueries implements gopherbouncedb.UserSQL with support for
+// MariaDB. Its queries differ from MySQLUserQueries only in the DDL emitted
+// for InitUsers, see mariadbqueries.go. Unlike MySQLUserQueries it has no
+// Migrations list or RegisterMigration: the DDL in InitS is the only schema
+// path.
+type MariaDBUserQueries struct {
+	InitS []string
+	GetUserS, GetUserByNameS, GetUserByEmailS, InsertUserS,
+	UpdateUserS, DeleteUserS, UpdateFieldsS string
+	Replacer *gopherbouncedb.SQLTemplateReplacer
+	RowNames map[string]string
+}
+
+func NewMariaDBUserQueries(replaceMapping map[string]string) *MariaDBUserQueries {
+	replacer := DefaultMySQLReplacer()
+	if replaceMapping != nil {
+		replacer.UpdateDict(replaceMapping)
+	}
+	res := &MariaDBUserQueries{}
+	res.Replacer = replacer
+	res.InitS = append(res.InitS, replacer.Apply(MariaDBUsersInit))
+	res.GetUserS = replacer.Apply(MariaDBQueryUserID)
+	res.GetUserByNameS = replacer.Apply(MariaDBQueryUsername)
+	res.GetUserByEmailS = replacer.Apply(MariaDBQueryUserEmail)
+	res.InsertUserS = replacer.Apply(MariaDBInsertUser)
+	res.UpdateUserS = replacer.Apply(MariaDBUpdateUser)
+	res.DeleteUserS = replacer.Apply(MariaDBDeleteUser)
+	res.UpdateFieldsS = replacer.Apply(MariaDBUpdateUserFields)
+	res.RowNames = DefaultMySQLUserRowNames
+	return res
+}
+
+func (q *MariaDBUserQueries) InitUsers() []string {
+	return q.InitS
+}
+
+func (q *MariaDBUserQueries) GetUser() string {
+	return q.GetUserS
+}
+
+func (q *MariaDBUserQueries) GetUserByName() string {
+	return q.GetUserByNameS
+}
+
+func (q *MariaDBUserQueries) GetUserByEmail() string {
+	return q.GetUserByEmailS
+}
+
+func (q *MariaDBUserQueries) InsertUser() string {
+	return q.InsertUserS
+}
+
+func (q *MariaDBUserQueries) UpdateUser(fields []string) string {
+	if len(fields) == 0 || !q.SupportsUserFields() {
+		return q.UpdateUserS
+	}
+	return buildUpdateUserStmt(q.UpdateFieldsS, q.RowNames, fields)
+}
+
+func (q *MariaDBUserQueries) DeleteUser() string {
+	return q.DeleteUserS
+}
+
+func (q *MariaDBUserQueries) SupportsUserFields() bool {
+	return q.UpdateFieldsS != ""
+}
+
+// MariaDBUserStorage is a user storage backed by MariaDB.
+type MariaDBUserStorage struct {
+	*gopherbouncedb.SQLUserStorage
+}
+
+func NewMariaDBUserStorage(db *sql.DB, replaceMapping map[string]string) *MariaDBUserStorage {
+	queries := NewMariaDBUserQueries(replaceMapping)
+	bridge := NewMariaDBBridge()
+	sqlStorage := gopherbouncedb.NewSQLUserStorage(db, queries, bridge)
+	return &MariaDBUserStorage{sqlStorage}
+}
+
+// MariaDBSessionQueries implements gopherbouncedb.SessionSQL with support
+// for MariaDB.
+type MariaDBSessionQueries struct {
+	InitS []string
+	InsertSessionS, GetSessionS, DeleteSessionS, CleanUpSessionS, DeleteForUserSessionS string
+	Replacer *gopherbouncedb.SQLTemplateReplacer
+}
+
+func NewMariaDBSessionQueries(replaceMapping map[string]string) *MariaDBSessionQueries {
+	replacer := DefaultMySQLReplacer()
+	if replaceMapping != nil {
+		replacer.UpdateDict(replaceMapping)
+	}
+	res := &MariaDBSessionQueries{}
+	res.Replacer = replacer
+	res.InitS = append(res.InitS, replacer.Apply(MariaDBSessionInit))
+	res.InsertSessionS = replacer.Apply(MariaDBInsertSession)
+	res.GetSessionS = replacer.Apply(MariaDBGetSession)
+	res.DeleteSessionS = replacer.Apply(MariaDBDeleteSession)
+	res.CleanUpSessionS = replacer.Apply(MariaDBCleanUpSession)
+	res.DeleteForUserSessionS = replacer.Apply(MariaDBDeleteForUser)
+	return res
+}
+
+func (q *MariaDBSessionQueries) InitSessions() []string {
+	return q.InitS
+}
+
+func (q *MariaDBSessionQueries) GetSession() string {
+	return q.GetSessionS
+}
+
+func (q *MariaDBSessionQueries) InsertSession() string {
+	return q.InsertSessionS
+}
+
+func (q *MariaDBSessionQueries) DeleteSession() string {
+	return q.DeleteSessionS
+}
+
+func (q *MariaDBSessionQueries) CleanUpSession() string {
+	return q.CleanUpSessionS
+}
+
+func (q *MariaDBSessionQueries) DeleteForUserSession() string {
+	return q.DeleteForUserSessionS
+}
+
+// MariaDBSessionStorage is a session storage backed by MariaDB.
+type MariaDBSessionStorage struct {
+	*gopherbouncedb.SQLSessionStorage
+}
+
+func NewMariaDBSessionStorage(db *sql.DB, replaceMapping map[string]string) *MariaDBSessionStorage {
+	queries := NewMariaDBSessionQueries(replaceMapping)
+	bridge := NewMariaDBBridge()
+	sqlStorage := gopherbouncedb.NewSQLSessionStorage(db, queries, bridge)
+	return &MariaDBSessionStorage{sqlStorage}
+}
+
+// MariaDBStorage combines a user storage and a session storage (both based
+// on MariaDB) to implement gopherbouncedb.GoauthStorage.
+type MariaDBStorage struct {
+	*MariaDBUserStorage
+	*MariaDBSessionStorage
+}
+
+// NewMariaDBStorage returns a new MariaDBStorage. It can be used wherever a
+// gopherbouncedb.GoauthStorage is expected, the same way NewMySQLStorage can,
+// just pointed at a MariaDB server instead.
+func NewMariaDBStorage(db *sql.DB, replaceMapping map[string]string) *MariaDBStorage {
+	return &MariaDBStorage{
+		NewMariaDBUserStorage(db, replaceMapping),
+		NewMariaDBSessionStorage(db, replaceMapping),
+	}
+}
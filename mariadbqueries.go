@@ -0,0 +1,80 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+// The MariaDB DDL mirrors the MySQL one in mysqlqueries.go, but pins an
+// explicit engine / charset / collation (MariaDB's defaults have drifted
+// from MySQL's over time) and stores timestamps with microsecond
+// precision via DATETIME(6).
+const (
+	MariaDBUsersInit = `CREATE TABLE IF NOT EXISTS $USERS_TABLE_NAME$ (
+id BIGINT AUTO_INCREMENT,
+username VARCHAR(150) NOT NULL UNIQUE,
+password VARCHAR(270) NOT NULL,
+email VARCHAR(254) NOT NULL $EMAIL_UNIQUE$,
+first_name VARCHAR(50) NOT NULL,
+last_name VARCHAR(150) NOT NULL,
+is_superuser BOOL NOT NULL,
+is_staff BOOL NOT NULL,
+is_active BOOL NOT NULL,
+date_joined DATETIME(6) NOT NULL,
+last_login DATETIME(6) NOT NULL,
+PRIMARY KEY(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+`
+	MariaDBQueryUserID = `SELECT * FROM $USERS_TABLE_NAME$ WHERE id=?;`
+
+	MariaDBQueryUsername = `SELECT * FROM $USERS_TABLE_NAME$ WHERE username=?;`
+
+	MariaDBQueryUserEmail = `SELECT * FROM $USERS_TABLE_NAME$ WHERE email=?;`
+
+	MariaDBInsertUser = `INSERT INTO $USERS_TABLE_NAME$(
+username, password, email, first_name, last_name, is_superuser, is_staff,
+is_active, date_joined, last_login)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	MariaDBUpdateUser = `UPDATE $USERS_TABLE_NAME$
+SET username=?, password=?, email=?, first_name=?, last_name=?,
+	is_superuser=?, is_staff=?, is_active=?, date_joined=?, last_login=?
+WHERE id=?;`
+
+	MariaDBDeleteUser = `DELETE FROM $USERS_TABLE_NAME$ WHERE id=?;`
+
+	MariaDBUpdateUserFields = `UPDATE $USERS_TABLE_NAME$
+SET $UPDATE_CONTENT$
+WHERE id=?;`
+)
+
+// The session DDL mirrors the (MySQL) session table: a session is keyed by
+// its id, belongs to a single user and carries an expiry date used by
+// CleanUpSession.
+const (
+	MariaDBSessionInit = `CREATE TABLE IF NOT EXISTS $SESSION_TABLE_NAME$ (
+id VARCHAR(150),
+user_id BIGINT NOT NULL,
+expire_date DATETIME(6) NOT NULL,
+PRIMARY KEY(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+`
+	MariaDBInsertSession = `INSERT INTO $SESSION_TABLE_NAME$(id, user_id, expire_date) VALUES(?, ?, ?);`
+
+	MariaDBGetSession = `SELECT * FROM $SESSION_TABLE_NAME$ WHERE id=?;`
+
+	MariaDBDeleteSession = `DELETE FROM $SESSION_TABLE_NAME$ WHERE id=?;`
+
+	MariaDBCleanUpSession = `DELETE FROM $SESSION_TABLE_NAME$ WHERE expire_date<?;`
+
+	MariaDBDeleteForUser = `DELETE FROM $SESSION_TABLE_NAME$ WHERE user_id=?;`
+)
@@ -0,0 +1,168 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/FabianWe/gopherbouncedb"
+)
+
+// schemaMigrationsUserRowID and schemaMigrationsSessionRowID identify the
+// row in schema_migrations each table's version is tracked under, so
+// auth_user and auth_session can be migrated independently.
+const (
+	schemaMigrationsUserRowID    = 1
+	schemaMigrationsSessionRowID = 2
+)
+
+// Migration describes a single, ordered schema change. Up and Down may use
+// the same $USERS_TABLE_NAME$ / $SESSION_TABLE_NAME$ placeholder as the
+// query templates in mysqlqueries.go; both are run through the owning
+// queries' gopherbouncedb.SQLTemplateReplacer before being executed.
+type Migration struct {
+	Version  int
+	Up, Down string
+}
+
+// defaultUserMigrations is the migration list every MySQLUserQueries starts
+// with: migration v1 is the original auth_user DDL from mysqlqueries.go.
+func defaultUserMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Up: MySQLUsersInit, Down: `DROP TABLE IF EXISTS $USERS_TABLE_NAME$;`},
+	}
+}
+
+// defaultSessionMigrations is the migration list every MySQLSessionQueries
+// starts with: migration v1 is the original auth_session DDL,
+// MySQLSessionInit.
+func defaultSessionMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Up: MySQLSessionInit, Down: `DROP TABLE IF EXISTS $SESSION_TABLE_NAME$;`},
+	}
+}
+
+// RegisterMigration appends a migration to q's own migration list, applied
+// by MySQLUserStorage.InitUsers / MigrateTo. Migrations must be registered
+// in ascending Version order; InitUsers always migrates up to the highest
+// version registered on q. Because the list lives on q rather than in a
+// package-level registry, two MySQLUserQueries instances (e.g. pointed at
+// different tables via replaceMapping for multi-tenant setups) never share
+// or race on migration state.
+func (q *MySQLUserQueries) RegisterMigration(m Migration) {
+	q.Migrations = append(q.Migrations, m)
+}
+
+// RegisterMigration appends a migration to q's own migration list, applied
+// by MySQLSessionStorage.InitSessions / MigrateTo. See
+// MySQLUserQueries.RegisterMigration.
+func (q *MySQLSessionQueries) RegisterMigration(m Migration) {
+	q.Migrations = append(q.Migrations, m)
+}
+
+// latestMigrationVersion returns the highest Version in migrations, or 0 if
+// migrations is empty.
+func latestMigrationVersion(migrations []Migration) int {
+	latest := 0
+	for _, m := range migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// migrateTo runs migrations against db so that rowID's tracked version ends
+// up at exactly version, applying Up migrations (if version is ahead of the
+// current one) or Down migrations (if version is behind it). The whole
+// operation runs in a single transaction with the schema_migrations row
+// locked via SELECT ... FOR UPDATE, so two processes starting up
+// concurrently can't apply the same migration twice.
+func migrateTo(ctx context.Context, db *sql.DB, replacer *gopherbouncedb.SQLTemplateReplacer, rowID int, migrations []Migration, version int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT IGNORE INTO schema_migrations(id, version) VALUES(?, 0);`, rowID); err != nil {
+		return err
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM schema_migrations WHERE id=? FOR UPDATE;`, rowID).Scan(&current); err != nil {
+		return err
+	}
+
+	switch {
+	case version > current:
+		for _, m := range migrations {
+			if m.Version > current && m.Version <= version {
+				if _, err := tx.ExecContext(ctx, replacer.Apply(m.Up)); err != nil {
+					return fmt.Errorf("applying migration %d: %w", m.Version, err)
+				}
+			}
+		}
+	case version < current:
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if m.Version <= current && m.Version > version {
+				if _, err := tx.ExecContext(ctx, replacer.Apply(m.Down)); err != nil {
+					return fmt.Errorf("rolling back migration %d: %w", m.Version, err)
+				}
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE schema_migrations SET version=? WHERE id=?;`, version, rowID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InitUsers creates the schema_migrations table if required and migrates
+// auth_user up to the highest version registered on s's queries. It
+// replaces the plain DDL exec of the embedded
+// gopherbouncedb.SQLUserStorage so that downstream users who called
+// RegisterMigration actually get their columns created.
+func (s *MySQLUserStorage) InitUsers() error {
+	if _, err := s.db.Exec(MySQLSchemaMigrationsInit); err != nil {
+		return err
+	}
+	return s.MigrateTo(context.Background(), latestMigrationVersion(s.queries.Migrations))
+}
+
+// MigrateTo migrates auth_user to exactly the given version. See migrateTo.
+func (s *MySQLUserStorage) MigrateTo(ctx context.Context, version int) error {
+	return migrateTo(ctx, s.db, s.replacer, schemaMigrationsUserRowID, s.queries.Migrations, version)
+}
+
+// InitSessions creates the schema_migrations table if required and
+// migrates auth_session up to the highest version registered on s's
+// queries. It replaces the plain DDL exec of the embedded
+// gopherbouncedb.SQLSessionStorage so that downstream users who called
+// RegisterMigration actually get their columns created.
+func (s *MySQLSessionStorage) InitSessions() error {
+	if _, err := s.db.Exec(MySQLSchemaMigrationsInit); err != nil {
+		return err
+	}
+	return s.MigrateTo(context.Background(), latestMigrationVersion(s.queries.Migrations))
+}
+
+// MigrateTo migrates auth_session to exactly the given version. See
+// migrateTo.
+func (s *MySQLSessionStorage) MigrateTo(ctx context.Context, version int) error {
+	return migrateTo(ctx, s.db, s.queries.Replacer, schemaMigrationsSessionRowID, s.queries.Migrations, version)
+}
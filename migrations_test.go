@@ -0,0 +1,159 @@
+// Copyright 2019 Fabian Wenzelmann
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gopherbouncemysql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/testcontainers/testcontainers-go"
+	"os"
+	"testing"
+)
+
+// newMigrationsTestDB opens a *sql.DB against a test server chosen the same
+// way as in the rest of the suite (a new ephemeral container for this call,
+// unless MYSQL_HOST is set) and registers a cleanup that drops its tables,
+// closes it and terminates the container.
+func newMigrationsTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := setupMySQLConfigString()
+	var container testcontainers.Container
+	if os.Getenv("MYSQL_HOST") == "" {
+		c, containerDSN, err := startTestContainer(context.Background(), defaultTestImage)
+		if err != nil {
+			t.Fatalf("can't start test container: %s", err.Error())
+		}
+		container = c
+		dsn = containerDSN
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("can't open database: %s", err.Error())
+	}
+	if err := removeData(db); err != nil {
+		t.Fatalf("can't clear tables: %s", err.Error())
+	}
+	if _, err := db.Exec(`DROP TABLE IF EXISTS schema_migrations;`); err != nil {
+		t.Fatalf("can't clear schema_migrations: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		db.Exec(`DROP TABLE IF EXISTS schema_migrations;`)
+		removeData(db)
+		db.Close()
+		if container != nil {
+			container.Terminate(context.Background())
+		}
+	})
+	return db
+}
+
+// assertSchemaVersion fails t unless schema_migrations' row for rowID reads
+// back as want.
+func assertSchemaVersion(t *testing.T, db *sql.DB, rowID, want int) {
+	t.Helper()
+	var got int
+	if err := db.QueryRow(`SELECT version FROM schema_migrations WHERE id=?;`, rowID).Scan(&got); err != nil {
+		t.Fatalf("reading schema_migrations: %s", err.Error())
+	}
+	if got != want {
+		t.Errorf("schema_migrations version = %d, want %d", got, want)
+	}
+}
+
+// hasColumn reports whether table has a column named column in the
+// connected database.
+func hasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+	var name string
+	err := db.QueryRow(
+		`SELECT COLUMN_NAME FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND COLUMN_NAME=?;`,
+		table, column).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false
+	case err != nil:
+		t.Fatalf("checking column %s.%s: %s", table, column, err.Error())
+	}
+	return true
+}
+
+// TestUserMigrations proves InitUsers creates auth_user and that a
+// RegisterMigration'd version is actually applied (and rolled back) by
+// MigrateTo.
+func TestUserMigrations(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	storage := NewMySQLUserStorage(db, nil)
+	if err := storage.InitUsers(); err != nil {
+		t.Fatalf("InitUsers: %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsUserRowID, 1)
+
+	storage.queries.RegisterMigration(Migration{
+		Version: 2,
+		Up:      `ALTER TABLE $USERS_TABLE_NAME$ ADD COLUMN nickname VARCHAR(150) NOT NULL DEFAULT '';`,
+		Down:    `ALTER TABLE $USERS_TABLE_NAME$ DROP COLUMN nickname;`,
+	})
+
+	if err := storage.MigrateTo(context.Background(), 2); err != nil {
+		t.Fatalf("MigrateTo(2): %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsUserRowID, 2)
+	if !hasColumn(t, db, "auth_user", "nickname") {
+		t.Error("expected auth_user.nickname to exist after MigrateTo(2)")
+	}
+
+	if err := storage.MigrateTo(context.Background(), 1); err != nil {
+		t.Fatalf("MigrateTo(1): %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsUserRowID, 1)
+	if hasColumn(t, db, "auth_user", "nickname") {
+		t.Error("expected auth_user.nickname to be gone after MigrateTo(1)")
+	}
+}
+
+// TestSessionMigrations is TestUserMigrations' counterpart for auth_session,
+// proving InitSessions and MigrateTo work independently of the user table's
+// schema_migrations row.
+func TestSessionMigrations(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	storage := NewMySQLSessionStorage(db, nil)
+	if err := storage.InitSessions(); err != nil {
+		t.Fatalf("InitSessions: %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsSessionRowID, 1)
+
+	storage.queries.RegisterMigration(Migration{
+		Version: 2,
+		Up:      `ALTER TABLE $SESSION_TABLE_NAME$ ADD COLUMN note VARCHAR(150) NOT NULL DEFAULT '';`,
+		Down:    `ALTER TABLE $SESSION_TABLE_NAME$ DROP COLUMN note;`,
+	})
+
+	if err := storage.MigrateTo(context.Background(), 2); err != nil {
+		t.Fatalf("MigrateTo(2): %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsSessionRowID, 2)
+	if !hasColumn(t, db, "auth_session", "note") {
+		t.Error("expected auth_session.note to exist after MigrateTo(2)")
+	}
+
+	if err := storage.MigrateTo(context.Background(), 1); err != nil {
+		t.Fatalf("MigrateTo(1): %s", err.Error())
+	}
+	assertSchemaVersion(t, db, schemaMigrationsSessionRowID, 1)
+	if hasColumn(t, db, "auth_session", "note") {
+		t.Error("expected auth_session.note to be gone after MigrateTo(1)")
+	}
+}
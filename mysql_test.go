@@ -15,16 +15,57 @@
 package gopherbouncemysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/FabianWe/gopherbouncedb"
 	"github.com/FabianWe/gopherbouncedb/testsuite"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
-func setupPostgreConfigString() string {
+// defaultTestImage is used to start an ephemeral test server whenever
+// MYSQL_HOST is not set. Pass WithImage to newMySQLUserTestBinding /
+// newMySQLSessionTestBinding to run the same suite against a different
+// server version, e.g. WithImage("mariadb:10").
+const defaultTestImage = "mysql:8.0"
+
+// bindingOption configures the Docker image a test binding spins up when no
+// externally managed database is configured via MYSQL_HOST.
+type bindingOption func(*testImage)
+
+type testImage struct {
+	image   string
+	mariaDB bool
+}
+
+// WithImage selects the Docker image used for the ephemeral MySQL/MariaDB
+// test container, e.g. WithImage("mariadb:10") to run the test suite
+// against MariaDB instead of the defaultTestImage. Images whose name
+// contains "mariadb" make the binding construct a MariaDBUserStorage /
+// MariaDBSessionStorage instead of the MySQL ones, so the MariaDB DDL in
+// mariadbqueries.go actually gets exercised.
+func WithImage(image string) bindingOption {
+	return func(t *testImage) {
+		t.image = image
+		t.mariaDB = strings.Contains(strings.ToLower(image), "mariadb")
+	}
+}
+
+func newTestImage(opts []bindingOption) testImage {
+	t := testImage{image: defaultTestImage}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+func setupMySQLConfigString() string {
 	host := os.Getenv("MYSQL_HOST")
 	if host == "" {
 		host = "localhost"
@@ -50,12 +91,42 @@ func setupPostgreConfigString() string {
 	return config
 }
 
-type mysqlUserTestBinding struct {
-	db *sql.DB
-}
-
-func newMySQLUserTestBinding() *mysqlUserTestBinding {
-	return &mysqlUserTestBinding{nil}
+// startTestContainer launches an ephemeral MySQL/MariaDB server for the
+// duration of a single test and returns a DSN that points at it. Used by
+// BeginInstance whenever MYSQL_HOST is not set, so go test ./... works out
+// of the box instead of requiring a developer-provided database.
+func startTestContainer(ctx context.Context, image string) (testcontainers.Container, string, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        image,
+		ExposedPorts: []string{"3306/tcp"},
+		Env: map[string]string{
+			"MYSQL_ROOT_PASSWORD": "password",
+			"MYSQL_DATABASE":      "mysql",
+		},
+		// The official mysql/mariadb images restart the server mid-init for
+		// their second bootstrap phase, so the port briefly listens, closes
+		// and reopens; wait.ForListeningPort can match the first, short-lived
+		// listen and hand back a connection the server is about to drop.
+		// Waiting for the startup log line (twice, since it's also logged
+		// once before that restart) is the reliable signal.
+		WaitingFor: wait.ForLog("ready for connections").WithOccurrence(2),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	port, err := container.MappedPort(ctx, "3306")
+	if err != nil {
+		return nil, "", err
+	}
+	return container, fmt.Sprintf("root:password@tcp(%s:%s)/mysql", host, port.Port()), nil
 }
 
 func removeData(db *sql.DB) error {
@@ -69,21 +140,43 @@ func removeData(db *sql.DB) error {
 	return err
 }
 
+type mysqlUserTestBinding struct {
+	db        *sql.DB
+	container testcontainers.Container
+	image     string
+	mariaDB   bool
+}
+
+func newMySQLUserTestBinding(opts ...bindingOption) *mysqlUserTestBinding {
+	t := newTestImage(opts)
+	return &mysqlUserTestBinding{image: t.image, mariaDB: t.mariaDB}
+}
+
 func (b *mysqlUserTestBinding) BeginInstance() gopherbouncedb.UserStorage {
+	dsn := setupMySQLConfigString()
+	if os.Getenv("MYSQL_HOST") == "" {
+		container, containerDSN, err := startTestContainer(context.Background(), b.image)
+		if err != nil {
+			panic(fmt.Sprintf("can't start %s test container: %s", b.image, err.Error()))
+		}
+		b.container = container
+		dsn = containerDSN
+	}
 	// create db
-	db, dbErr := sql.Open("mysql", setupPostgreConfigString())
+	db, dbErr := sql.Open("mysql", dsn)
 	if dbErr != nil {
 		panic(fmt.Sprintf("Can't create database: %s", dbErr.Error()))
 	}
-	// don't know exactly why this is required, but here we are
-	db.SetMaxIdleConns(0)
+	db.SetConnMaxLifetime(3 * time.Minute)
 	b.db = db
 	// clear tables
 	if removeErr := removeData(b.db); removeErr != nil {
 		log.Printf("can't delete table entries: %s\n", removeErr.Error())
 	}
-	storage := NewMySQLUserStorage(db, nil)
-	return storage
+	if b.mariaDB {
+		return NewMariaDBUserStorage(db, nil)
+	}
+	return NewMySQLUserStorage(db, nil)
 }
 
 func (b *mysqlUserTestBinding) CloseInstance(s gopherbouncedb.UserStorage) {
@@ -93,48 +186,101 @@ func (b *mysqlUserTestBinding) CloseInstance(s gopherbouncedb.UserStorage) {
 	if closeErr := b.db.Close(); closeErr != nil {
 		panic(fmt.Sprintf("Can't close database: %s", closeErr.Error()))
 	}
+	if b.container != nil {
+		if termErr := b.container.Terminate(context.Background()); termErr != nil {
+			log.Printf("can't terminate test container: %s\n", termErr.Error())
+		}
+	}
 }
 
+// matrixImages is the set of server versions the user and session suites
+// below run against, matching xorm's and Harbor's version-matrix approach:
+// every Test* function runs once per image instead of only against MySQL,
+// so the MariaDB DDL in mariadbqueries.go gets the same coverage as the
+// MySQL one in mysqlqueries.go.
+var matrixImages = []string{defaultTestImage, "mariadb:10"}
+
 func TestInit(t *testing.T) {
-	testsuite.TestInitSuite(newMySQLUserTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestInitSuite(newMySQLUserTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestInsert(t *testing.T) {
-	testsuite.TestInsertSuite(newMySQLUserTestBinding(), true, t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestInsertSuite(newMySQLUserTestBinding(WithImage(image)), true, t)
+		})
+	}
 }
 
 func TestLookup(t *testing.T) {
-	testsuite.TestLookupSuite(newMySQLUserTestBinding(), true, t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestLookupSuite(newMySQLUserTestBinding(WithImage(image)), true, t)
+		})
+	}
 }
 
 func TestUpdate(t *testing.T) {
-	testsuite.TestUpdateUserSuite(newMySQLUserTestBinding(), true, t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestUpdateUserSuite(newMySQLUserTestBinding(WithImage(image)), true, t)
+		})
+	}
 }
 
 func TestDelete(t *testing.T) {
-	testsuite.TestDeleteUserSuite(newMySQLUserTestBinding(), true, t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestDeleteUserSuite(newMySQLUserTestBinding(WithImage(image)), true, t)
+		})
+	}
 }
+
 type mysqlSessionTestBinding struct {
-	db *sql.DB
+	db        *sql.DB
+	container testcontainers.Container
+	image     string
+	mariaDB   bool
 }
 
-func newMySQLSessionTestBinding() *mysqlSessionTestBinding {
-	return &mysqlSessionTestBinding{nil}
+func newMySQLSessionTestBinding(opts ...bindingOption) *mysqlSessionTestBinding {
+	t := newTestImage(opts)
+	return &mysqlSessionTestBinding{image: t.image, mariaDB: t.mariaDB}
 }
 
 func (b *mysqlSessionTestBinding) BeginInstance() gopherbouncedb.SessionStorage {
+	dsn := setupMySQLConfigString()
+	if os.Getenv("MYSQL_HOST") == "" {
+		container, containerDSN, err := startTestContainer(context.Background(), b.image)
+		if err != nil {
+			panic(fmt.Sprintf("can't start %s test container: %s", b.image, err.Error()))
+		}
+		b.container = container
+		dsn = containerDSN
+	}
 	// create db
-	db, dbErr := sql.Open("mysql", setupPostgreConfigString())
+	db, dbErr := sql.Open("mysql", dsn)
 	if dbErr != nil {
 		panic(fmt.Sprintf("Can't create database: %s", dbErr.Error()))
 	}
-	// don't know exactly why this is required, but here we are
-	db.SetMaxIdleConns(0)
+	db.SetConnMaxLifetime(3 * time.Minute)
 	b.db = db
 	// clear tables
 	if removeErr := removeData(b.db); removeErr != nil {
 		log.Printf("can't delete table entries: %s\n", removeErr.Error())
 	}
+	if b.mariaDB {
+		return NewMariaDBSessionStorage(db, nil)
+	}
 	return NewMySQLSessionStorage(db, nil)
 }
 
@@ -145,28 +291,63 @@ func (b *mysqlSessionTestBinding) CloseInstance(s gopherbouncedb.SessionStorage)
 	if closeErr := b.db.Close(); closeErr != nil {
 		panic(fmt.Sprintf("Can't close database: %s", closeErr.Error()))
 	}
+	if b.container != nil {
+		if termErr := b.container.Terminate(context.Background()); termErr != nil {
+			log.Printf("can't terminate test container: %s\n", termErr.Error())
+		}
+	}
 }
 
 func TestSessionInit(t *testing.T) {
-	testsuite.TestInitSessionSuite(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestInitSessionSuite(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestSessionInsert(t *testing.T) {
-	testsuite.TestSessionInsert(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestSessionInsert(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestSessionGet(t *testing.T) {
-	testsuite.TestSessionGet(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestSessionGet(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestSessionDelete(t *testing.T) {
-	testsuite.TestSessionDelete(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestSessionDelete(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestSessionCleanUp(t *testing.T) {
-	testsuite.TestSessionCleanUp(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestSessionCleanUp(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
 
 func TestSessionDeleteForUser(t *testing.T) {
-	testsuite.TestSessionDeleteForUser(newMySQLSessionTestBinding(), t)
+	for _, image := range matrixImages {
+		image := image
+		t.Run(image, func(t *testing.T) {
+			testsuite.TestSessionDeleteForUser(newMySQLSessionTestBinding(WithImage(image)), t)
+		})
+	}
 }
@@ -52,3 +52,18 @@ WHERE id=?;`
 SET $UPDATE_CONTENT$
 WHERE id=?;`
 )
+
+// MySQLSchemaMigrationsInit creates the table used by the migration
+// framework (see migrations.go) to track which DDL versions have already
+// been applied to a given database. It is not part of the template
+// replacement system: the table name is internal to this package and not
+// meant to be reconfigured alongside $USERS_TABLE_NAME$ / $SESSION_TABLE_NAME$.
+//
+// A row is kept per migrated table (see schemaMigrationsUserRowID /
+// schemaMigrationsSessionRowID in migrations.go), not just one global
+// version, so auth_user and auth_session can be migrated independently.
+const MySQLSchemaMigrationsInit = `CREATE TABLE IF NOT EXISTS schema_migrations (
+id TINYINT NOT NULL,
+version INT NOT NULL DEFAULT 0,
+PRIMARY KEY(id)
+);`
@@ -22,6 +22,7 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -89,6 +90,13 @@ type MySQLUserQueries struct {
 	UpdateUserS, DeleteUserS, UpdateFieldsS string
 	Replacer *gopherbouncedb.SQLTemplateReplacer
 	RowNames map[string]string
+	// Migrations holds this instance's ordered schema migrations for
+	// auth_user, applied by MySQLUserStorage.InitUsers / MigrateTo. It starts
+	// out with migration v1 (the DDL above) and can be extended with
+	// RegisterMigration. Each MySQLUserQueries keeps its own list, so two
+	// instances (e.g. pointed at different tables via replaceMapping) never
+	// share migration state.
+	Migrations []Migration
 }
 
 func DefaultMySQLReplacer() *gopherbouncedb.SQLTemplateReplacer {
@@ -112,6 +120,7 @@ func NewMySQLUserQueries(replaceMapping map[string]string) *MySQLUserQueries {
 	res.DeleteUserS = replacer.Apply(MySQLDeleteUser)
 	res.UpdateFieldsS = replacer.Apply(MySQLUpdateUserFields)
 	res.RowNames = DefaultMySQLUserRowNames
+	res.Migrations = defaultUserMigrations()
 	return res
 }
 
@@ -135,21 +144,29 @@ func (q *MySQLUserQueries) InsertUser() string {
 	return q.InsertUserS
 }
 
-func (q *MySQLUserQueries) UpdateUser(fields []string) string {
-	if len(fields) == 0 || !q.SupportsUserFields() {
-		return q.UpdateUserS
-	}
+// buildUpdateUserStmt substitutes the $UPDATE_CONTENT$ placeholder in
+// updateFieldsS with "col=?" pairs for fields, looking each field up in
+// rowNames. It backs both MySQLUserQueries.UpdateUser and
+// MariaDBUserQueries.UpdateUser, which share the same $UPDATE_CONTENT$
+// convention.
+func buildUpdateUserStmt(updateFieldsS string, rowNames map[string]string, fields []string) string {
 	updates := make([]string, len(fields))
 	for i, fieldName := range fields {
-		if colName, has := q.RowNames[fieldName]; has {
+		if colName, has := rowNames[fieldName]; has {
 			updates[i] = colName + "=?"
 		} else {
 			panic(fmt.Sprintf("invalid field name \"%s\": Must be a valid field name of gopherbouncedb.UserModel", fieldName))
 		}
 	}
 	updateStr := strings.Join(updates, ",")
-	stmt := strings.Replace(q.UpdateFieldsS, "$UPDATE_CONTENT$", updateStr, 1)
-	return stmt
+	return strings.Replace(updateFieldsS, "$UPDATE_CONTENT$", updateStr, 1)
+}
+
+func (q *MySQLUserQueries) UpdateUser(fields []string) string {
+	if len(fields) == 0 || !q.SupportsUserFields() {
+		return q.UpdateUserS
+	}
+	return buildUpdateUserStmt(q.UpdateFieldsS, q.RowNames, fields)
 }
 
 func (q *MySQLUserQueries) DeleteUser() string {
@@ -162,14 +179,24 @@ func (q *MySQLUserQueries) SupportsUserFields() bool {
 
 type MySQLUserStorage struct {
 	*gopherbouncedb.SQLUserStorage
+	db       *sql.DB
+	queries  *MySQLUserQueries
+	replacer *gopherbouncedb.SQLTemplateReplacer
+	// stmtCache holds prepared statements for the hot-path single-row
+	// queries, keyed by their SQL text. See batch.go.
+	stmtCache sync.Map
 }
 
 func NewMySQLUserStorage(db *sql.DB, replaceMapping map[string]string) *MySQLUserStorage {
 	queries := NewMySQLUserQueries(replaceMapping)
 	bridge := NewMySQLBridge()
 	sqlStorage := gopherbouncedb.NewSQLUserStorage(db, queries, bridge)
-	res := MySQLUserStorage{sqlStorage}
-	return &res
+	return &MySQLUserStorage{
+		SQLUserStorage: sqlStorage,
+		db:             db,
+		queries:        queries,
+		replacer:       queries.Replacer,
+	}
 }
 
 // MySQLSessionQueries implements gopherbouncedb.SessionSQL with support for MySQL.
@@ -177,6 +204,10 @@ type MySQLSessionQueries struct {
 	InitS []string
 	InsertSessionS, GetSessionS, DeleteSessionS, CleanUpSessionS, DeleteForUserSessionS string
 	Replacer *gopherbouncedb.SQLTemplateReplacer
+	// Migrations holds this instance's ordered schema migrations for
+	// auth_session, applied by MySQLSessionStorage.InitSessions / MigrateTo.
+	// See MySQLUserQueries.Migrations.
+	Migrations []Migration
 }
 
 // NewMySQLSessionQueries returns new queries given the replacement mapping that is used to update
@@ -192,6 +223,7 @@ func NewMySQLSessionQueries(replaceMapping map[string]string) *MySQLSessionQueri
 	res := &MySQLSessionQueries{}
 	res.Replacer = replacer
 	res.InitS = append(res.InitS, replacer.Apply(MySQLSessionInit))
+	res.Migrations = defaultSessionMigrations()
 	res.InsertSessionS = replacer.Apply(MySQLInsertSession)
 	res.GetSessionS = replacer.Apply(MySQLGetSession)
 	res.DeleteSessionS = replacer.Apply(MySQLDeleteSession)
@@ -227,6 +259,8 @@ func (q *MySQLSessionQueries) DeleteForUserSession() string {
 // MySQLSessionStorage is as session storage based on MySQL.
 type MySQLSessionStorage struct {
 	*gopherbouncedb.SQLSessionStorage
+	db      *sql.DB
+	queries *MySQLSessionQueries
 }
 
 // NewMySQLSessionStorage creates a new MySQL session storage given the database connection
@@ -237,7 +271,7 @@ func NewMySQLSessionStorage(db *sql.DB, replaceMapping map[string]string) *MySQL
 	queries := NewMySQLSessionQueries(replaceMapping)
 	bridge := NewMySQLBridge()
 	sqlStorage := gopherbouncedb.NewSQLSessionStorage(db, queries, bridge)
-	return &MySQLSessionStorage{sqlStorage}
+	return &MySQLSessionStorage{sqlStorage, db, queries}
 }
 
 // MySQLStorage combines a user storage and a session storage (both based on MySQL)
@@ -245,6 +279,7 @@ func NewMySQLSessionStorage(db *sql.DB, replaceMapping map[string]string) *MySQL
 type MySQLStorage struct {
 	*MySQLUserStorage
 	*MySQLSessionStorage
+	db *sql.DB
 }
 
 // NewMySQLStorage returns a new MySQLStorage.
@@ -252,5 +287,6 @@ func NewMySQLStorage(db *sql.DB, replaceMapping map[string]string) *MySQLStorage
 	return &MySQLStorage{
 		NewMySQLUserStorage(db, replaceMapping),
 		NewMySQLSessionStorage(db, replaceMapping),
+		db,
 	}
 }
\ No newline at end of file